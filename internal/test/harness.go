@@ -0,0 +1,41 @@
+package test
+
+import (
+	"context"
+
+	"go.sia.tech/core/types"
+)
+
+// A Harness coordinates a set of test nodes so that multi-node tests
+// (e.g. reorgs) can be driven deterministically instead of relying on
+// sleeps to wait for mining or peer synchronization to settle.
+type Harness struct {
+	nodes []*Wallet
+}
+
+// NewHarness returns a Harness managing the given nodes.
+func NewHarness(nodes ...*Wallet) *Harness {
+	return &Harness{nodes: nodes}
+}
+
+// Tick mines a single block on node, paying the reward to addr. It
+// returns once node's wallet has finished processing the resulting
+// update, per the guarantee documented on Wallet.MineBlocks.
+func (h *Harness) Tick(node *Wallet, addr types.Address) error {
+	return node.MineBlocks(addr, 1)
+}
+
+// Sync blocks until every node managed by the harness has converged on
+// the same chain tip as nodes[0], or ctx is cancelled.
+func (h *Harness) Sync(ctx context.Context) error {
+	if len(h.nodes) == 0 {
+		return nil
+	}
+	leader := h.nodes[0]
+	for _, n := range h.nodes[1:] {
+		if err := n.SyncToTip(ctx, leader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
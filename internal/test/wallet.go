@@ -0,0 +1,166 @@
+// Package test provides helpers for constructing fully-wired hostd nodes
+// (consensus, gateway, transaction pool, and wallet) for use in tests.
+package test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+
+	"go.sia.tech/core/consensus"
+	"go.sia.tech/core/types"
+	"go.sia.tech/coreutils"
+	"go.sia.tech/coreutils/chain"
+	"go.sia.tech/coreutils/syncer"
+	"go.sia.tech/hostd/wallet"
+)
+
+// A Wallet is a test node that wires a wallet up to an in-memory consensus
+// set, gateway, and transaction pool so it can mine blocks and exchange
+// transactions with other test nodes.
+type Wallet struct {
+	*wallet.Wallet
+
+	cm *chain.Manager
+	s  *syncer.Syncer
+	tp *chain.TxPool
+
+	store *wallet.SQLiteStore
+}
+
+// NewWallet creates a new test node with a wallet derived from priv. The
+// wallet's store is persisted beneath dir; chain state is kept in memory.
+func NewWallet(priv types.PrivateKey, dir string) (*Wallet, error) {
+	network, genesisBlock := coreutils.Network()
+
+	dbstore, tipState, err := chain.NewDBStore(chain.NewMemDB(), network, genesisBlock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chain store: %w", err)
+	}
+	cm := chain.NewManager(dbstore, tipState)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	s := syncer.New(l, cm, syncer.NewMemPeerStore(), syncer.Header{
+		GenesisID:  network.GenesisState().Index.ID,
+		UniqueID:   syncer.GenerateUniqueID(),
+		NetAddress: l.Addr().String(),
+	})
+	go s.Run()
+
+	tp := chain.NewTxPool(cm)
+
+	addr := types.StandardUnlockHash(priv.PublicKey())
+	store, err := wallet.NewSQLiteStore(filepath.Join(dir, "wallet.sqlite3"), addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wallet store: %w", err)
+	}
+	tip, err := store.Tip()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet tip: %w", err)
+	}
+
+	w := wallet.NewWallet(priv, cm, tp, store)
+	// register the wallet itself as the chain subscriber; it forwards
+	// updates to the store's idempotent UpdateTx contract
+	cm.AddSubscriber(w, tip)
+
+	return &Wallet{
+		Wallet: w,
+		cm:     cm,
+		s:      s,
+		tp:     tp,
+		store:  store,
+	}, nil
+}
+
+// GatewayAddr returns the address the node's syncer is listening on.
+func (w *Wallet) GatewayAddr() string {
+	return w.s.Addr().String()
+}
+
+// ConnectPeer connects the node's syncer to the peer at addr.
+func (w *Wallet) ConnectPeer(addr string) error {
+	_, err := w.s.Connect(addr)
+	return err
+}
+
+// MineBlocks mines n blocks, sending the reward of each to addr. Both v1
+// and v2 transactions currently in the pool are included. AddBlocks
+// notifies subscribers synchronously, so by the time MineBlocks returns,
+// the wallet's SQLite store has already processed every resulting
+// ApplyUpdate; callers do not need to sleep or poll for it.
+func (w *Wallet) MineBlocks(addr types.Address, n int) error {
+	for i := 0; i < n; i++ {
+		cs := w.cm.TipState()
+		b, ok := coreutils.MineBlock(cs, addr, w.tp.Transactions(), w.tp.V2Transactions())
+		if !ok {
+			return fmt.Errorf("failed to mine block at height %v", cs.Index.Height+1)
+		} else if err := w.cm.AddBlocks([]types.Block{b}); err != nil {
+			return fmt.Errorf("failed to add block: %w", err)
+		}
+	}
+	return nil
+}
+
+// TipState returns the node's current consensus tip state, letting a test
+// snapshot a fork point before mining the blocks it means to reorg away.
+func (w *Wallet) TipState() consensus.State {
+	return w.cm.TipState()
+}
+
+// MineBlocksAt mines n empty blocks extending the chain at cs, rather than
+// the node's current tip, so a test can build a competing fork from an
+// earlier point in its own history instead of spinning up a second node.
+// It returns the resulting tip state so the caller can extend the fork
+// further.
+func (w *Wallet) MineBlocksAt(cs consensus.State, addr types.Address, n int) (consensus.State, error) {
+	for i := 0; i < n; i++ {
+		b, ok := coreutils.MineBlock(cs, addr, nil, nil)
+		if !ok {
+			return consensus.State{}, fmt.Errorf("failed to mine block at height %v", cs.Index.Height+1)
+		} else if err := w.cm.AddBlocks([]types.Block{b}); err != nil {
+			return consensus.State{}, fmt.Errorf("failed to add block: %w", err)
+		}
+		next, err := w.cm.State(b.ID())
+		if err != nil {
+			return consensus.State{}, fmt.Errorf("failed to get state for mined block: %w", err)
+		}
+		cs = next
+	}
+	return cs, nil
+}
+
+// SendTransactionSet broadcasts an already-signed v1 transaction set to the
+// node's pool, letting a test rebroadcast a transaction built before a
+// reorg instead of constructing a new one.
+func (w *Wallet) SendTransactionSet(txns []types.Transaction) error {
+	return w.tp.AddTransactionSet(txns)
+}
+
+// SyncToTip blocks until the node's chain state has converged on other's
+// current tip, or ctx is cancelled. It is intended to replace
+// sleep-based waits for a reorg to propagate after ConnectPeer.
+func (w *Wallet) SyncToTip(ctx context.Context, other *Wallet) error {
+	target := other.cm.TipState().Index
+	t := time.NewTicker(10 * time.Millisecond)
+	defer t.Stop()
+	for w.cm.TipState().Index != target {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting to sync to %v: %w", target, ctx.Err())
+		case <-t.C:
+		}
+	}
+	return w.Wallet.WaitForTip(ctx, target)
+}
+
+// Close shuts down the node's syncer and wallet store.
+func (w *Wallet) Close() error {
+	w.s.Close()
+	return w.store.Close()
+}
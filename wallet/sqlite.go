@@ -0,0 +1,492 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/coreutils/chain"
+
+	_ "modernc.org/sqlite" // sqlite driver
+)
+
+// schema creates the tables used by the sqlite-backed wallet store. It is
+// idempotent so it can be run every time the store is opened.
+const schema = `CREATE TABLE IF NOT EXISTS wallet_siacoin_elements (
+	id BLOB PRIMARY KEY,
+	siacoin_output BLOB NOT NULL,
+	maturity_height INTEGER NOT NULL,
+	block_height INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS wallet_immature_elements (
+	id BLOB PRIMARY KEY,
+	siacoin_output BLOB NOT NULL,
+	maturity_height INTEGER NOT NULL,
+	block_height INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS wallet_transactions (
+	transaction_id BLOB PRIMARY KEY,
+	block_height INTEGER NOT NULL,
+	block_id BLOB NOT NULL,
+	inflow BLOB NOT NULL,
+	outflow BLOB NOT NULL,
+	source TEXT NOT NULL,
+	timestamp INTEGER NOT NULL,
+	transaction_data BLOB NOT NULL,
+	label TEXT NOT NULL DEFAULT '',
+	metadata BLOB,
+	sort_id INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS wallet_pending_labels (
+	transaction_id BLOB PRIMARY KEY,
+	label TEXT NOT NULL,
+	metadata BLOB
+);
+
+CREATE TABLE IF NOT EXISTS wallet_tip (
+	id INTEGER PRIMARY KEY CHECK (id = 0),
+	height INTEGER NOT NULL,
+	block_id BLOB NOT NULL
+);`
+
+// A SQLiteStore is a SingleAddressStore backed by a SQLite database.
+type SQLiteStore struct {
+	addr types.Address
+	db   *sql.DB
+}
+
+// NewSQLiteStore opens (or creates) a wallet store at path, tracking the
+// outputs and transactions relevant to addr.
+func NewSQLiteStore(path string, addr types.Address) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	} else if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+	} else if _, err := db.Exec(`INSERT OR IGNORE INTO wallet_tip (id, height, block_id) VALUES (0, 0, ?)`, types.BlockID{}[:]); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize tip: %w", err)
+	}
+	return &SQLiteStore{addr: addr, db: db}, nil
+}
+
+// Close closes the underlying database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Tip implements SingleAddressStore.
+func (s *SQLiteStore) Tip() (types.ChainIndex, error) {
+	var index types.ChainIndex
+	var blockID []byte
+	err := s.db.QueryRow(`SELECT height, block_id FROM wallet_tip WHERE id = 0`).Scan(&index.Height, &blockID)
+	if err != nil {
+		return types.ChainIndex{}, err
+	}
+	copy(index.ID[:], blockID)
+	return index, nil
+}
+
+// UnspentSiacoinElements implements SingleAddressStore. Immature elements
+// are tracked separately and are not returned until they mature.
+func (s *SQLiteStore) UnspentSiacoinElements() ([]types.SiacoinElement, error) {
+	rows, err := s.db.Query(`SELECT id, siacoin_output, maturity_height FROM wallet_siacoin_elements`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var elements []types.SiacoinElement
+	for rows.Next() {
+		var id, output []byte
+		var maturityHeight uint64
+		if err := rows.Scan(&id, &output, &maturityHeight); err != nil {
+			return nil, err
+		}
+		var sce types.SiacoinElement
+		if err := json.Unmarshal(output, &sce.SiacoinOutput); err != nil {
+			return nil, fmt.Errorf("failed to decode siacoin output: %w", err)
+		}
+		copy(sce.ID[:], id)
+		sce.MaturityHeight = maturityHeight
+		elements = append(elements, sce)
+	}
+	return elements, rows.Err()
+}
+
+// ImmatureSiacoinElements returns the siacoin elements owned by the wallet
+// that have not yet reached their maturity height.
+func (s *SQLiteStore) ImmatureSiacoinElements() ([]types.SiacoinElement, error) {
+	rows, err := s.db.Query(`SELECT id, siacoin_output, maturity_height FROM wallet_immature_elements`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var elements []types.SiacoinElement
+	for rows.Next() {
+		var id, output []byte
+		var maturityHeight uint64
+		if err := rows.Scan(&id, &output, &maturityHeight); err != nil {
+			return nil, err
+		}
+		var sce types.SiacoinElement
+		if err := json.Unmarshal(output, &sce.SiacoinOutput); err != nil {
+			return nil, fmt.Errorf("failed to decode siacoin output: %w", err)
+		}
+		copy(sce.ID[:], id)
+		sce.MaturityHeight = maturityHeight
+		elements = append(elements, sce)
+	}
+	return elements, rows.Err()
+}
+
+// TransactionCount implements SingleAddressStore.
+func (s *SQLiteStore) TransactionCount() (count uint64, err error) {
+	err = s.db.QueryRow(`SELECT COUNT(*) FROM wallet_transactions`).Scan(&count)
+	return
+}
+
+// SetTransactionLabel implements SingleAddressStore. If the transaction has
+// already confirmed, its row is updated directly. Otherwise the label is
+// staged in wallet_pending_labels and attached by ApplyChainUpdate once the
+// transaction confirms -- including after a reorg re-mines it under the
+// same id.
+func (s *SQLiteStore) SetTransactionLabel(id types.TransactionID, label string, metadata json.RawMessage) error {
+	res, err := s.db.Exec(`UPDATE wallet_transactions SET label = ?, metadata = ? WHERE transaction_id = ?`, label, []byte(metadata), id[:])
+	if err != nil {
+		return fmt.Errorf("failed to update transaction label: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n > 0 {
+		return nil
+	}
+	if _, err := s.db.Exec(`INSERT OR REPLACE INTO wallet_pending_labels (transaction_id, label, metadata) VALUES (?, ?, ?)`,
+		id[:], label, []byte(metadata)); err != nil {
+		return fmt.Errorf("failed to stage transaction label: %w", err)
+	}
+	return nil
+}
+
+// Transactions implements SingleAddressStore.
+func (s *SQLiteStore) Transactions(limit, offset int, filter TransactionFilter) ([]Transaction, error) {
+	rows, err := s.db.Query(`SELECT transaction_id, transaction_data, block_height, block_id, inflow, outflow, source, timestamp, label, metadata
+		FROM wallet_transactions WHERE ? = '' OR label = ? ORDER BY sort_id DESC LIMIT ? OFFSET ?`,
+		filter.Label, filter.Label, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var txns []Transaction
+	for rows.Next() {
+		var txn Transaction
+		var txnID, txnData, blockID, inflow, outflow, metadata []byte
+		var timestamp int64
+		if err := rows.Scan(&txnID, &txnData, &txn.Index.Height, &blockID, &inflow, &outflow, &txn.Source, &timestamp, &txn.Label, &metadata); err != nil {
+			return nil, err
+		}
+		if txn.Source == TxnSourceV2Transaction {
+			txn.V2 = new(types.V2Transaction)
+			if err := json.Unmarshal(txnData, txn.V2); err != nil {
+				return nil, fmt.Errorf("failed to decode v2 transaction: %w", err)
+			}
+		} else if err := json.Unmarshal(txnData, &txn.Transaction); err != nil {
+			return nil, fmt.Errorf("failed to decode transaction: %w", err)
+		}
+		copy(txn.Index.ID[:], blockID)
+		if txn.Source == TxnSourceMinerPayout || txn.Source == TxnSourceSiafundClaim {
+			copy(txn.pseudoID[:], txnID)
+		}
+		if len(metadata) > 0 {
+			txn.Metadata = json.RawMessage(metadata)
+		}
+		if err := txn.Inflow.UnmarshalText(inflow); err != nil {
+			return nil, fmt.Errorf("failed to decode inflow: %w", err)
+		} else if err := txn.Outflow.UnmarshalText(outflow); err != nil {
+			return nil, fmt.Errorf("failed to decode outflow: %w", err)
+		}
+		txn.Timestamp = time.Unix(timestamp, 0)
+		txns = append(txns, txn)
+	}
+	return txns, rows.Err()
+}
+
+// ApplyChainUpdate implements UpdateTx, adding new siacoin elements and
+// transactions relevant to the wallet's address. It is idempotent: if cau's
+// resulting index is not strictly greater than the store's persisted tip,
+// it is a no-op, so that replaying an update after a crash (before the new
+// tip was durably committed) does not double-apply it.
+func (s *SQLiteStore) ApplyChainUpdate(cau *chain.ApplyUpdate) error {
+	tip, err := s.Tip()
+	if err != nil {
+		return fmt.Errorf("failed to get tip: %w", err)
+	} else if cau.State.Index.Height <= tip.Height && tip.Height != 0 {
+		return nil // already applied
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	spentValues := make(map[types.Hash256]types.Currency)
+	cau.ForEachSiacoinElement(func(sce types.SiacoinElement, spent bool) {
+		if sce.SiacoinOutput.Address != s.addr {
+			return
+		}
+		if spent {
+			spentValues[sce.ID] = sce.SiacoinOutput.Value
+			_, err = tx.Exec(`DELETE FROM wallet_siacoin_elements WHERE id = ?`, sce.ID[:])
+			if err == nil {
+				_, err = tx.Exec(`DELETE FROM wallet_immature_elements WHERE id = ?`, sce.ID[:])
+			}
+			return
+		}
+		output, jerr := json.Marshal(sce.SiacoinOutput)
+		if jerr != nil {
+			err = jerr
+			return
+		}
+		table := "wallet_siacoin_elements"
+		if sce.MaturityHeight > cau.State.Index.Height {
+			table = "wallet_immature_elements"
+		}
+		_, err = tx.Exec(`INSERT OR REPLACE INTO `+table+` (id, siacoin_output, maturity_height, block_height)
+			VALUES (?, ?, ?, ?)`, sce.ID[:], output, sce.MaturityHeight, cau.State.Index.Height)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update siacoin elements: %w", err)
+	}
+
+	// promote any immature elements that have now matured
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO wallet_siacoin_elements (id, siacoin_output, maturity_height, block_height)
+		SELECT id, siacoin_output, maturity_height, block_height FROM wallet_immature_elements WHERE maturity_height <= ?`,
+		cau.State.Index.Height); err != nil {
+		return fmt.Errorf("failed to mature siacoin elements: %w", err)
+	} else if _, err := tx.Exec(`DELETE FROM wallet_immature_elements WHERE maturity_height <= ?`, cau.State.Index.Height); err != nil {
+		return fmt.Errorf("failed to prune matured siacoin elements: %w", err)
+	}
+
+	for _, txn := range relevantTransactions(cau, s.addr, spentValues) {
+		var data []byte
+		var jerr error
+		if txn.V2 != nil {
+			data, jerr = json.Marshal(txn.V2)
+		} else {
+			data, jerr = json.Marshal(txn.Transaction)
+		}
+		if jerr != nil {
+			return fmt.Errorf("failed to encode transaction: %w", jerr)
+		}
+		inflow, oerr := txn.Inflow.MarshalText()
+		if oerr != nil {
+			return oerr
+		}
+		outflow, oerr := txn.Outflow.MarshalText()
+		if oerr != nil {
+			return oerr
+		}
+
+		id := txn.ID()
+		var label string
+		var metadata []byte
+		if err := tx.QueryRow(`SELECT label, metadata FROM wallet_pending_labels WHERE transaction_id = ?`, id[:]).Scan(&label, &metadata); err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to check pending label: %w", err)
+		}
+
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO wallet_transactions
+			(transaction_id, block_height, block_id, inflow, outflow, source, timestamp, transaction_data, label, metadata, sort_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id[:], cau.State.Index.Height, cau.State.Index.ID[:], inflow, outflow, txn.Source, txn.Timestamp.Unix(), data, label, metadata, cau.State.Index.Height); err != nil {
+			return fmt.Errorf("failed to insert transaction: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM wallet_pending_labels WHERE transaction_id = ?`, id[:]); err != nil {
+			return fmt.Errorf("failed to clear pending label: %w", err)
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE wallet_tip SET height = ?, block_id = ? WHERE id = 0`, cau.State.Index.Height, cau.State.Index.ID[:]); err != nil {
+		return fmt.Errorf("failed to update tip: %w", err)
+	}
+	return tx.Commit()
+}
+
+// RevertChainUpdate implements UpdateTx, fully undoing the effects of a
+// prior ApplyChainUpdate for the reverted block.
+func (s *SQLiteStore) RevertChainUpdate(cru *chain.RevertUpdate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	revertHeight := cru.State.Index.Height + 1
+	// cru.State.Index is the post-revert (parent) tip; wallet_transactions
+	// rows were stamped by ApplyChainUpdate with the reverted block's own
+	// index, so label-preservation and deletion below must filter on that
+	// index instead.
+	revertedIndex := types.ChainIndex{Height: revertHeight, ID: cru.Block.ID()}
+
+	// demote elements that matured in the block being reverted
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO wallet_immature_elements (id, siacoin_output, maturity_height, block_height)
+		SELECT id, siacoin_output, maturity_height, block_height FROM wallet_siacoin_elements WHERE maturity_height = ?`,
+		revertHeight); err != nil {
+		return fmt.Errorf("failed to demature siacoin elements: %w", err)
+	} else if _, err := tx.Exec(`DELETE FROM wallet_siacoin_elements WHERE maturity_height = ?`, revertHeight); err != nil {
+		return fmt.Errorf("failed to prune dematured siacoin elements: %w", err)
+	}
+
+	cru.ForEachSiacoinElement(func(sce types.SiacoinElement, spent bool) {
+		if sce.SiacoinOutput.Address != s.addr {
+			return
+		}
+		if spent {
+			// the output was spent in the reverted block; restore it to
+			// whichever set it belongs in
+			output, jerr := json.Marshal(sce.SiacoinOutput)
+			if jerr != nil {
+				err = jerr
+				return
+			}
+			table := "wallet_siacoin_elements"
+			if sce.MaturityHeight > revertHeight-1 {
+				table = "wallet_immature_elements"
+			}
+			_, err = tx.Exec(`INSERT OR REPLACE INTO `+table+` (id, siacoin_output, maturity_height, block_height)
+				VALUES (?, ?, ?, ?)`, sce.ID[:], output, sce.MaturityHeight, cru.State.Index.Height)
+			return
+		}
+		// the output was created in the reverted block; remove it from both
+		// sets
+		_, err = tx.Exec(`DELETE FROM wallet_siacoin_elements WHERE id = ?`, sce.ID[:])
+		if err == nil {
+			_, err = tx.Exec(`DELETE FROM wallet_immature_elements WHERE id = ?`, sce.ID[:])
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revert siacoin elements: %w", err)
+	}
+
+	// preserve any labels on reverted transactions so they reattach if the
+	// same transaction is re-mined later
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO wallet_pending_labels (transaction_id, label, metadata)
+		SELECT transaction_id, label, metadata FROM wallet_transactions WHERE block_id = ? AND label != ''`,
+		revertedIndex.ID[:]); err != nil {
+		return fmt.Errorf("failed to preserve transaction labels: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM wallet_transactions WHERE block_id = ?`, revertedIndex.ID[:]); err != nil {
+		return fmt.Errorf("failed to revert transactions: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE wallet_tip SET height = ?, block_id = ? WHERE id = 0`, cru.State.Index.Height, cru.State.Index.ID[:]); err != nil {
+		return fmt.Errorf("failed to update tip: %w", err)
+	}
+	return tx.Commit()
+}
+
+// relevantTransactions extracts the wallet transactions from cau that
+// involve addr, including miner payouts. spentValues maps the IDs of
+// siacoin elements owned by addr that were spent in cau to their value, so
+// that each transaction's Outflow can be computed.
+func relevantTransactions(cau *chain.ApplyUpdate, addr types.Address, spentValues map[types.Hash256]types.Currency) []Transaction {
+	var txns []Transaction
+	for _, txn := range cau.Block.Transactions {
+		var inflow, outflow types.Currency
+		relevant := false
+		for _, sco := range txn.SiacoinOutputs {
+			if sco.Address == addr {
+				inflow = inflow.Add(sco.Value)
+				relevant = true
+			}
+		}
+		for _, sci := range txn.SiacoinInputs {
+			if v, ok := spentValues[types.Hash256(sci.ParentID)]; ok {
+				outflow = outflow.Add(v)
+				relevant = true
+			}
+		}
+		if !relevant {
+			continue
+		}
+		txns = append(txns, Transaction{
+			Transaction: txn,
+			Index:       cau.State.Index,
+			Inflow:      inflow,
+			Outflow:     outflow,
+			Source:      TxnSourceTransaction,
+			Timestamp:   cau.Block.Timestamp,
+		})
+	}
+
+	if cau.Block.V2 != nil {
+		for _, txn := range cau.Block.V2.Transactions {
+			v2txn := txn
+			var inflow, outflow types.Currency
+			relevant := false
+			for _, sco := range txn.SiacoinOutputs {
+				if sco.Address == addr {
+					inflow = inflow.Add(sco.Value)
+					relevant = true
+				}
+			}
+			for _, sci := range txn.SiacoinInputs {
+				if v, ok := spentValues[types.Hash256(sci.Parent.ID)]; ok {
+					outflow = outflow.Add(v)
+					relevant = true
+				}
+			}
+			if !relevant {
+				continue
+			}
+			txns = append(txns, Transaction{
+				V2:        &v2txn,
+				Index:     cau.State.Index,
+				Inflow:    inflow,
+				Outflow:   outflow,
+				Source:    TxnSourceV2Transaction,
+				Timestamp: cau.Block.Timestamp,
+			})
+		}
+	}
+
+	for i, sco := range cau.Block.MinerPayouts {
+		if sco.Address != addr {
+			continue
+		}
+		txns = append(txns, Transaction{
+			Index:     cau.State.Index,
+			Inflow:    sco.Value,
+			Source:    TxnSourceMinerPayout,
+			Timestamp: cau.Block.Timestamp,
+			pseudoID:  pseudoTransactionID(cau.State.Index, addr, i),
+		})
+	}
+	return txns
+}
+
+// pseudoTransactionID derives a synthetic, per-entry transaction id for
+// wallet transactions with no underlying types.Transaction to hash, such as
+// the i'th miner payout or siafund claim paid to addr in the block at
+// index. Without this, every such entry would hash to the same id (that of
+// the zero-valued embedded types.Transaction) and collide in
+// wallet_transactions, silently overwriting all but the most recent one.
+func pseudoTransactionID(index types.ChainIndex, addr types.Address, i int) types.TransactionID {
+	var buf [8 + 32 + 32 + 8]byte
+	binary.LittleEndian.PutUint64(buf[:8], index.Height)
+	copy(buf[8:40], index.ID[:])
+	copy(buf[40:72], addr[:])
+	binary.LittleEndian.PutUint64(buf[72:], uint64(i))
+	return types.TransactionID(sha256.Sum256(buf[:]))
+}
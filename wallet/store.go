@@ -0,0 +1,54 @@
+package wallet
+
+import (
+	"encoding/json"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/coreutils/chain"
+)
+
+// An UpdateTx atomically commits the effects of a single chain update to
+// the wallet's store. Implementations must be idempotent with respect to
+// the update's resulting ChainIndex, so that replaying an update after a
+// crash (before the new tip was durably committed) does not double-apply
+// it.
+type UpdateTx interface {
+	// ApplyChainUpdate commits the balance and transaction changes
+	// described by cau.
+	ApplyChainUpdate(cau *chain.ApplyUpdate) error
+	// RevertChainUpdate undoes the balance and transaction changes
+	// described by cru, fully reversing a prior ApplyChainUpdate.
+	RevertChainUpdate(cru *chain.RevertUpdate) error
+}
+
+// A SingleAddressStore stores the state of a single-address wallet.
+// Implementations are assumed to be thread-safe.
+type SingleAddressStore interface {
+	UpdateTx
+
+	// Tip returns the chain index of the last block processed by the
+	// store.
+	Tip() (types.ChainIndex, error)
+
+	// UnspentSiacoinElements returns the set of siacoin elements owned by
+	// the wallet that have matured and not yet been spent in a confirmed
+	// transaction.
+	UnspentSiacoinElements() ([]types.SiacoinElement, error)
+	// ImmatureSiacoinElements returns the set of siacoin elements owned by
+	// the wallet that have not yet reached their maturity height, such as
+	// recent miner payouts.
+	ImmatureSiacoinElements() ([]types.SiacoinElement, error)
+
+	// Transactions returns the most recent transactions matching filter,
+	// sorted newest first, skipping the first offset results.
+	Transactions(limit, offset int, filter TransactionFilter) ([]Transaction, error)
+	// TransactionCount returns the total number of transactions in the
+	// wallet.
+	TransactionCount() (uint64, error)
+
+	// SetTransactionLabel sets the label and metadata of the transaction
+	// with the given id. If the transaction has not yet confirmed, the
+	// label is staged and attached once it does -- including after a reorg
+	// re-mines the same transaction under the same id.
+	SetTransactionLabel(id types.TransactionID, label string, metadata json.RawMessage) error
+}
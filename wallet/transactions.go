@@ -0,0 +1,233 @@
+package wallet
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.sia.tech/core/consensus"
+	"go.sia.tech/core/types"
+)
+
+// sendOptions holds the options applied by SendOption functions.
+type sendOptions struct {
+	label    string
+	metadata json.RawMessage
+}
+
+// A SendOption customizes the behavior of SendSiacoins.
+type SendOption func(*sendOptions)
+
+// WithLabel sets the label recorded for the sent transaction, as with
+// LabelTransaction.
+func WithLabel(label string) SendOption {
+	return func(o *sendOptions) { o.label = label }
+}
+
+// FundTransaction adds siacoin inputs worth at least amount to txn, using
+// only confirmed, mature outputs that are not already spent by a pending
+// transaction in the pool. It returns the IDs of the parent elements so the
+// caller can sign them with SignTransaction, along with a release function
+// that must be called exactly once, after the transaction has been
+// broadcast or discarded. Until release is called, w.mu is held, so that a
+// concurrent Fund*Transaction call cannot select the same confirmed outputs
+// before this transaction reaches the pool.
+func (w *Wallet) FundTransaction(txn *types.Transaction, amount types.Currency) ([]types.Hash256, func(), error) {
+	if amount.IsZero() {
+		return nil, func() {}, nil
+	}
+
+	w.mu.Lock()
+
+	// UnspentSiacoinElements only returns matured outputs; immature ones
+	// (e.g. recent miner payouts) are tracked separately by the store.
+	utxos, err := w.store.UnspentSiacoinElements()
+	if err != nil {
+		w.mu.Unlock()
+		return nil, nil, fmt.Errorf("failed to get unspent outputs: %w", err)
+	}
+
+	inUse := w.reservedOutputs()
+
+	var selected []types.SiacoinElement
+	var selectedSum types.Currency
+	for _, sce := range utxos {
+		if inUse[sce.ID] {
+			continue // already reserved by a pending transaction
+		}
+		selected = append(selected, sce)
+		selectedSum = selectedSum.Add(sce.SiacoinOutput.Value)
+		if selectedSum.Cmp(amount) >= 0 {
+			break
+		}
+	}
+	if selectedSum.Cmp(amount) < 0 {
+		w.mu.Unlock()
+		return nil, nil, ErrInsufficientFunds
+	}
+
+	var toSign []types.Hash256
+	for _, sce := range selected {
+		txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
+			ParentID:         types.SiacoinOutputID(sce.ID),
+			UnlockConditions: types.StandardUnlockConditions(w.priv.PublicKey()),
+		})
+		toSign = append(toSign, types.Hash256(sce.ID))
+	}
+	if change := selectedSum.Sub(amount); !change.IsZero() {
+		txn.SiacoinOutputs = append(txn.SiacoinOutputs, types.SiacoinOutput{
+			Value:   change,
+			Address: w.addr,
+		})
+	}
+
+	return toSign, w.mu.Unlock, nil
+}
+
+// SignTransaction signs the inputs of txn identified by toSign with the
+// wallet's private key.
+func (w *Wallet) SignTransaction(cs types.ChainIndex, txn *types.Transaction, toSign []types.Hash256) error {
+	for _, id := range toSign {
+		for _, sci := range txn.SiacoinInputs {
+			if types.Hash256(sci.ParentID) != id {
+				continue
+			}
+			sig := w.priv.SignHash(w.cm.TipState().WholeSigHash(*txn, id, 0, 0, nil))
+			txn.Signatures = append(txn.Signatures, types.TransactionSignature{
+				ParentID:       id,
+				CoveredFields:  types.CoveredFields{WholeTransaction: true},
+				PublicKeyIndex: 0,
+				Signature:      sig[:],
+			})
+		}
+	}
+	return nil
+}
+
+// SendSiacoins creates, funds, signs, and broadcasts a transaction that
+// pays each of the given outputs its value.
+func (w *Wallet) SendSiacoins(outputs []types.SiacoinOutput, opts ...SendOption) (types.Transaction, error) {
+	var options sendOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var total types.Currency
+	for _, sco := range outputs {
+		total = total.Add(sco.Value)
+	}
+
+	txn := types.Transaction{SiacoinOutputs: outputs}
+	toSign, release, err := w.FundTransaction(&txn, total)
+	if err != nil {
+		return types.Transaction{}, fmt.Errorf("failed to fund transaction: %w", err)
+	}
+	defer release()
+
+	if err := w.SignTransaction(w.cm.TipState().Index, &txn, toSign); err != nil {
+		return types.Transaction{}, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	if err := w.tp.AddTransactionSet([]types.Transaction{txn}); err != nil {
+		return types.Transaction{}, fmt.Errorf("failed to broadcast transaction: %w", err)
+	}
+	if options.label != "" || options.metadata != nil {
+		if err := w.store.SetTransactionLabel(txn.ID(), options.label, options.metadata); err != nil {
+			return types.Transaction{}, fmt.Errorf("failed to label transaction: %w", err)
+		}
+	}
+	return txn, nil
+}
+
+// FundV2Transaction adds siacoin inputs worth at least amount to txn, using
+// the same coin selection as FundTransaction. It returns the indices of the
+// added inputs that must be signed with SignV2Transaction, along with a
+// release function with the same contract as FundTransaction's: it must be
+// called exactly once, after the transaction has been broadcast or
+// discarded, and w.mu is held until then.
+func (w *Wallet) FundV2Transaction(cs consensus.State, txn *types.V2Transaction, amount types.Currency) ([]int, func(), error) {
+	if amount.IsZero() {
+		return nil, func() {}, nil
+	}
+
+	w.mu.Lock()
+
+	utxos, err := w.store.UnspentSiacoinElements()
+	if err != nil {
+		w.mu.Unlock()
+		return nil, nil, fmt.Errorf("failed to get unspent outputs: %w", err)
+	}
+
+	inUse := w.reservedOutputs()
+
+	var selected []types.SiacoinElement
+	var selectedSum types.Currency
+	for _, sce := range utxos {
+		if inUse[sce.ID] {
+			continue // already reserved by a pending transaction
+		}
+		selected = append(selected, sce)
+		selectedSum = selectedSum.Add(sce.SiacoinOutput.Value)
+		if selectedSum.Cmp(amount) >= 0 {
+			break
+		}
+	}
+	if selectedSum.Cmp(amount) < 0 {
+		w.mu.Unlock()
+		return nil, nil, ErrInsufficientFunds
+	}
+
+	var toSign []int
+	for _, sce := range selected {
+		txn.SiacoinInputs = append(txn.SiacoinInputs, types.V2SiacoinInput{
+			Parent: sce,
+		})
+		toSign = append(toSign, len(txn.SiacoinInputs)-1)
+	}
+	if change := selectedSum.Sub(amount); !change.IsZero() {
+		txn.SiacoinOutputs = append(txn.SiacoinOutputs, types.SiacoinOutput{
+			Value:   change,
+			Address: w.addr,
+		})
+	}
+
+	return toSign, w.mu.Unlock, nil
+}
+
+// SignV2Transaction signs the inputs of txn identified by toSign with the
+// wallet's private key.
+func (w *Wallet) SignV2Transaction(cs consensus.State, txn *types.V2Transaction, toSign []int) error {
+	policy := types.SpendPolicy{Type: types.PolicyTypeUnlockConditions(types.StandardUnlockConditions(w.priv.PublicKey()))}
+	sigHash := cs.InputSigHash(*txn)
+	sig := w.priv.SignHash(sigHash)
+	for _, i := range toSign {
+		txn.SiacoinInputs[i].SatisfiedPolicy = types.SatisfiedPolicy{
+			Policy:     policy,
+			Signatures: []types.Signature{sig},
+		}
+	}
+	return nil
+}
+
+// SendV2Siacoins creates, funds, signs, and broadcasts a v2 transaction that
+// pays each of the given outputs its value.
+func (w *Wallet) SendV2Siacoins(outputs []types.SiacoinOutput) (types.V2Transaction, error) {
+	var total types.Currency
+	for _, sco := range outputs {
+		total = total.Add(sco.Value)
+	}
+
+	cs := w.cm.TipState()
+	txn := types.V2Transaction{SiacoinOutputs: outputs}
+	toSign, release, err := w.FundV2Transaction(cs, &txn, total)
+	if err != nil {
+		return types.V2Transaction{}, fmt.Errorf("failed to fund transaction: %w", err)
+	}
+	defer release()
+
+	if err := w.SignV2Transaction(cs, &txn, toSign); err != nil {
+		return types.V2Transaction{}, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	if err := w.tp.AddV2TransactionSet(cs.Index, []types.V2Transaction{txn}); err != nil {
+		return types.V2Transaction{}, fmt.Errorf("failed to broadcast transaction: %w", err)
+	}
+	return txn, nil
+}
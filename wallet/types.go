@@ -0,0 +1,103 @@
+package wallet
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.sia.tech/core/types"
+)
+
+// A TxnSource identifies the origin of a wallet transaction.
+type TxnSource string
+
+const (
+	// TxnSourceTransaction is a transaction broadcast by this wallet or a peer.
+	TxnSourceTransaction TxnSource = "transaction"
+	// TxnSourceMinerPayout is a block reward or subsidy paid to the wallet's address.
+	TxnSourceMinerPayout TxnSource = "miner payout"
+	// TxnSourceSiafundClaim is a siafund claim payout.
+	TxnSourceSiafundClaim TxnSource = "siafund claim"
+	// TxnSourceContract is a payout from a resolved storage contract.
+	TxnSourceContract TxnSource = "contract"
+	// TxnSourceV2Transaction is a v2 transaction broadcast by this wallet or
+	// a peer.
+	TxnSourceV2Transaction TxnSource = "v2 transaction"
+)
+
+// A Transaction is a transaction relevant to the wallet's address, annotated
+// with the amounts it moved and where it came from. V2 is set instead of
+// embedding types.Transaction when Source is TxnSourceV2Transaction.
+type Transaction struct {
+	types.Transaction
+
+	V2 *types.V2Transaction `json:"v2,omitempty"`
+
+	Index     types.ChainIndex `json:"index"`
+	Inflow    types.Currency   `json:"inflow"`
+	Outflow   types.Currency   `json:"outflow"`
+	Source    TxnSource        `json:"source"`
+	Timestamp time.Time        `json:"timestamp"`
+
+	// Label is a short, caller-assigned tag, e.g. "contract formation" or
+	// "refund", set via LabelTransaction or WithLabel.
+	Label string `json:"label,omitempty"`
+	// Metadata is arbitrary caller-assigned data associated with the
+	// transaction via LabelTransaction.
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+
+	// pseudoID overrides ID() for sources that have no underlying
+	// transaction to hash (miner payouts, siafund claims): their embedded
+	// types.Transaction is left at its zero value, so without an override
+	// every such entry in a given process would hash identically.
+	pseudoID types.TransactionID
+}
+
+// A TransactionFilter narrows the results returned by Transactions.
+type TransactionFilter struct {
+	// Label, if non-empty, restricts results to transactions with a
+	// matching Label.
+	Label string
+}
+
+// ID returns the identifier of the underlying v1 or v2 transaction, or the
+// synthetic per-entry id assigned by the store for sources with no
+// underlying transaction, such as miner payouts and siafund claims.
+func (t Transaction) ID() types.TransactionID {
+	if t.pseudoID != (types.TransactionID{}) {
+		return t.pseudoID
+	}
+	if t.V2 != nil {
+		return t.V2.ID()
+	}
+	return t.Transaction.ID()
+}
+
+// A Balance is a wallet's balance broken down by spendability.
+type Balance struct {
+	// Spendable is the balance that can currently be spent -- confirmed
+	// outputs that are mature and not already reserved by a pending
+	// transaction.
+	Spendable types.Currency `json:"spendable"`
+	// Confirmed is the sum of all confirmed siacoin outputs owned by the
+	// wallet, including immature ones.
+	Confirmed types.Currency `json:"confirmed"`
+	// Immature is the portion of Confirmed that has not yet matured, e.g.
+	// miner payouts and contract outputs still within the maturity delay.
+	Immature types.Currency `json:"immature"`
+	// Unconfirmed is the net change to the wallet's balance from
+	// transactions in the transaction pool that have not been confirmed yet:
+	// the sum, per relevant pool transaction, of its outputs paid to the
+	// wallet's address minus its inputs that spend the wallet's own
+	// outputs. A transaction whose outflow exceeds its inflow (e.g. a pure
+	// outgoing send) contributes nothing, since Currency cannot represent a
+	// negative amount.
+	Unconfirmed types.Currency `json:"unconfirmed"`
+}
+
+// Equals returns true if b and b2 are equal.
+func (b Balance) Equals(b2 Balance) bool {
+	return b.Spendable.Equals(b2.Spendable) &&
+		b.Confirmed.Equals(b2.Confirmed) &&
+		b.Immature.Equals(b2.Immature) &&
+		b.Unconfirmed.Equals(b2.Unconfirmed)
+}
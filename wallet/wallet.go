@@ -0,0 +1,255 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.sia.tech/core/consensus"
+	"go.sia.tech/core/types"
+	"go.sia.tech/coreutils/chain"
+)
+
+// ErrInsufficientFunds is returned when the wallet does not have enough
+// spendable siacoins to fund a transaction.
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// A ChainManager tracks the current state of the blockchain.
+type ChainManager interface {
+	TipState() consensus.State
+}
+
+// A TransactionPool broadcasts transactions and tracks unconfirmed ones.
+type TransactionPool interface {
+	// Transactions returns the v1 transactions currently in the pool.
+	Transactions() []types.Transaction
+	// AddTransactionSet validates and broadcasts a v1 transaction set to the
+	// network.
+	AddTransactionSet(txns []types.Transaction) error
+
+	// V2Transactions returns the v2 transactions currently in the pool.
+	V2Transactions() []types.V2Transaction
+	// AddV2TransactionSet validates and broadcasts a v2 transaction set to
+	// the network.
+	AddV2TransactionSet(index types.ChainIndex, txns []types.V2Transaction) error
+}
+
+// A Wallet tracks the outputs and transactions relevant to a single
+// address.
+type Wallet struct {
+	priv types.PrivateKey
+	addr types.Address
+
+	cm    ChainManager
+	tp    TransactionPool
+	store SingleAddressStore
+
+	// mu is held by Fund*Transaction from coin selection until the caller
+	// releases it (after broadcasting or discarding the transaction), so
+	// that two concurrent sends cannot select and sign the same confirmed
+	// outputs before either reaches the pool.
+	mu sync.Mutex
+
+	tipMu   sync.Mutex // guards tip; also tipCond's lock
+	tip     types.ChainIndex
+	tipCond sync.Cond
+}
+
+// NewWallet creates a new single-address wallet using priv as its spend
+// policy key.
+func NewWallet(priv types.PrivateKey, cm ChainManager, tp TransactionPool, store SingleAddressStore) *Wallet {
+	w := &Wallet{
+		priv:  priv,
+		addr:  types.StandardUnlockHash(priv.PublicKey()),
+		cm:    cm,
+		tp:    tp,
+		store: store,
+	}
+	w.tipCond.L = &w.tipMu
+	if tip, err := store.Tip(); err == nil {
+		w.tip = tip
+	}
+	return w
+}
+
+// Address returns the wallet's address.
+func (w *Wallet) Address() types.Address {
+	return w.addr
+}
+
+// Balance returns the wallet's balance, broken down by spendability.
+func (w *Wallet) Balance() (Balance, error) {
+	utxos, err := w.store.UnspentSiacoinElements()
+	if err != nil {
+		return Balance{}, fmt.Errorf("failed to get unspent outputs: %w", err)
+	}
+	immature, err := w.store.ImmatureSiacoinElements()
+	if err != nil {
+		return Balance{}, fmt.Errorf("failed to get immature outputs: %w", err)
+	}
+
+	inUse := w.reservedOutputs()
+
+	// owned maps the ids of the wallet's own confirmed (mature or immature)
+	// siacoin elements to their value, so that Unconfirmed can recognize
+	// pending transactions that spend them, the same way relevantTransactions
+	// recognizes spends of confirmed elements.
+	owned := make(map[types.Hash256]types.Currency, len(utxos)+len(immature))
+
+	var b Balance
+	for _, sce := range utxos {
+		owned[types.Hash256(sce.ID)] = sce.SiacoinOutput.Value
+		b.Confirmed = b.Confirmed.Add(sce.SiacoinOutput.Value)
+		if inUse[sce.ID] {
+			continue
+		}
+		b.Spendable = b.Spendable.Add(sce.SiacoinOutput.Value)
+	}
+	for _, sce := range immature {
+		owned[types.Hash256(sce.ID)] = sce.SiacoinOutput.Value
+		b.Confirmed = b.Confirmed.Add(sce.SiacoinOutput.Value)
+		b.Immature = b.Immature.Add(sce.SiacoinOutput.Value)
+	}
+
+	for _, txn := range w.tp.Transactions() {
+		var inflow, outflow types.Currency
+		for _, sco := range txn.SiacoinOutputs {
+			if sco.Address == w.addr {
+				inflow = inflow.Add(sco.Value)
+			}
+		}
+		for _, sci := range txn.SiacoinInputs {
+			if v, ok := owned[types.Hash256(sci.ParentID)]; ok {
+				outflow = outflow.Add(v)
+			}
+		}
+		if inflow.Cmp(outflow) > 0 {
+			b.Unconfirmed = b.Unconfirmed.Add(inflow.Sub(outflow))
+		}
+	}
+	for _, txn := range w.tp.V2Transactions() {
+		var inflow, outflow types.Currency
+		for _, sco := range txn.SiacoinOutputs {
+			if sco.Address == w.addr {
+				inflow = inflow.Add(sco.Value)
+			}
+		}
+		for _, sci := range txn.SiacoinInputs {
+			if sci.Parent.SiacoinOutput.Address == w.addr {
+				outflow = outflow.Add(sci.Parent.SiacoinOutput.Value)
+			}
+		}
+		if inflow.Cmp(outflow) > 0 {
+			b.Unconfirmed = b.Unconfirmed.Add(inflow.Sub(outflow))
+		}
+	}
+
+	return b, nil
+}
+
+// reservedOutputs returns the set of siacoin element IDs spent by v1 and v2
+// transactions currently in the transaction pool.
+func (w *Wallet) reservedOutputs() map[types.Hash256]bool {
+	inUse := make(map[types.Hash256]bool)
+	for _, txn := range w.tp.Transactions() {
+		for _, sci := range txn.SiacoinInputs {
+			inUse[types.Hash256(sci.ParentID)] = true
+		}
+	}
+	for _, txn := range w.tp.V2Transactions() {
+		for _, sci := range txn.SiacoinInputs {
+			inUse[types.Hash256(sci.Parent.ID)] = true
+		}
+	}
+	return inUse
+}
+
+// TransactionCount returns the total number of transactions relevant to the
+// wallet's address.
+func (w *Wallet) TransactionCount() (uint64, error) {
+	return w.store.TransactionCount()
+}
+
+// Transactions returns up to limit transactions matching filter relevant to
+// the wallet's address, skipping the first offset, sorted newest first.
+func (w *Wallet) Transactions(limit, offset int, filter TransactionFilter) ([]Transaction, error) {
+	return w.store.Transactions(limit, offset, filter)
+}
+
+// LabelTransaction sets the label and metadata of the transaction with the
+// given id, for use in host operator dashboards and accounting. The
+// transaction need not have confirmed yet.
+func (w *Wallet) LabelTransaction(id types.TransactionID, label string, metadata json.RawMessage) error {
+	return w.store.SetTransactionLabel(id, label, metadata)
+}
+
+// Tip returns the consensus index the wallet has most recently processed.
+func (w *Wallet) Tip() types.ChainIndex {
+	w.tipMu.Lock()
+	defer w.tipMu.Unlock()
+	return w.tip
+}
+
+// WaitForTip blocks until the height of the wallet's processed tip has
+// reached index.Height, or ctx is cancelled. Only heights are compared; the
+// block ID of index is not checked.
+func (w *Wallet) WaitForTip(ctx context.Context, index types.ChainIndex) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.tipMu.Lock()
+		defer w.tipMu.Unlock()
+		for w.tip.Height < index.Height && ctx.Err() == nil {
+			w.tipCond.Wait()
+		}
+	}()
+	// tipCond is only broadcast by setTip, so without a watcher the
+	// goroutine above would leak until the next chain update if ctx is
+	// cancelled first.
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.tipMu.Lock()
+			w.tipCond.Broadcast()
+			w.tipMu.Unlock()
+		case <-done:
+		}
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// setTip records the wallet's current processed tip and wakes any callers
+// blocked in WaitForTip.
+func (w *Wallet) setTip(index types.ChainIndex) {
+	w.tipMu.Lock()
+	defer w.tipMu.Unlock()
+	w.tip = index
+	w.tipCond.Broadcast()
+}
+
+// ProcessChainApplyUpdate implements chain.Subscriber, forwarding the
+// update to the store's idempotent UpdateTx contract.
+func (w *Wallet) ProcessChainApplyUpdate(cau *chain.ApplyUpdate, mayCommit bool) error {
+	if err := w.store.ApplyChainUpdate(cau); err != nil {
+		return fmt.Errorf("failed to apply chain update: %w", err)
+	}
+	w.setTip(cau.State.Index)
+	return nil
+}
+
+// ProcessChainRevertUpdate implements chain.Subscriber, forwarding the
+// update to the store's idempotent UpdateTx contract.
+func (w *Wallet) ProcessChainRevertUpdate(cru *chain.RevertUpdate) error {
+	if err := w.store.RevertChainUpdate(cru); err != nil {
+		return fmt.Errorf("failed to revert chain update: %w", err)
+	}
+	w.setTip(cru.State.Index)
+	return nil
+}
@@ -1,6 +1,7 @@
 package wallet_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -19,40 +20,49 @@ func TestWallet(t *testing.T) {
 	defer node1.Close()
 
 	w := node1.Wallet
+	harness := test.NewHarness(node1)
 
-	_, balance, err := w.Balance()
+	balance, err := w.Balance()
 	if err != nil {
 		t.Fatal(err)
-	} else if !balance.Equals(types.ZeroCurrency) {
+	} else if !balance.Spendable.Equals(types.ZeroCurrency) || !balance.Confirmed.Equals(types.ZeroCurrency) {
 		t.Fatalf("expected zero balance, got %v", balance)
 	}
 
 	// mine a block to fund the wallet
-	if err := node1.MineBlocks(w.Address(), 1); err != nil {
+	if err := harness.Tick(node1, w.Address()); err != nil {
 		t.Fatal(err)
 	}
 
-	// the outputs have not matured yet
-	_, balance, err = w.Balance()
+	// the payout is confirmed but has not matured yet, so it should not be
+	// spendable
+	balance, err = w.Balance()
 	if err != nil {
 		t.Fatal(err)
-	} else if !balance.Equals(types.ZeroCurrency) {
-		t.Fatalf("expected zero balance, got %v", balance)
+	} else if !balance.Spendable.Equals(types.ZeroCurrency) {
+		t.Fatalf("expected zero spendable balance, got %v", balance.Spendable)
+	} else if balance.Confirmed.IsZero() {
+		t.Fatal("expected non-zero confirmed balance")
+	} else if !balance.Immature.Equals(balance.Confirmed) {
+		t.Fatalf("expected immature balance to equal confirmed balance, got %v and %v", balance.Immature, balance.Confirmed)
 	}
 
 	// mine until the first output has matured
 	if err := node1.MineBlocks(types.Address{}, int(stypes.MaturityDelay)); err != nil {
 		t.Fatal(err)
 	}
-	time.Sleep(500 * time.Millisecond) // sleep for consensus sync
 
 	// check the wallet's reported balance
 	expectedBalance := (consensus.State{}).BlockReward()
-	_, balance, err = w.Balance()
+	balance, err = w.Balance()
 	if err != nil {
 		t.Fatal(err)
-	} else if !balance.Equals(expectedBalance) {
-		t.Fatalf("expected %d balance, got %d", expectedBalance, balance)
+	} else if !balance.Spendable.Equals(expectedBalance) {
+		t.Fatalf("expected %d spendable balance, got %d", expectedBalance, balance.Spendable)
+	} else if !balance.Confirmed.Equals(expectedBalance) {
+		t.Fatalf("expected %d confirmed balance, got %d", expectedBalance, balance.Confirmed)
+	} else if !balance.Immature.Equals(types.ZeroCurrency) {
+		t.Fatalf("expected zero immature balance, got %v", balance.Immature)
 	}
 
 	// check that the wallet has a single transaction
@@ -64,7 +74,7 @@ func TestWallet(t *testing.T) {
 	}
 
 	// check that the payout transaction was created
-	txns, err := w.Transactions(100, 0)
+	txns, err := w.Transactions(100, 0, wallet.TransactionFilter{})
 	if err != nil {
 		t.Fatal(err)
 	} else if len(txns) != 1 {
@@ -81,45 +91,68 @@ func TestWallet(t *testing.T) {
 			Address: w.Address(),
 		}
 	}
-	splitTxn, err := node1.SendSiacoins(splitOutputs)
+	splitTxn, err := node1.SendSiacoins(splitOutputs, wallet.WithLabel("split"))
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	// the split transaction is in the pool but not yet confirmed; since it
+	// only touches the wallet's own address, its inflow and outflow cancel
+	// out and it should have no net effect on Unconfirmed
+	balance, err = w.Balance()
+	if err != nil {
+		t.Fatal(err)
+	} else if !balance.Unconfirmed.IsZero() {
+		t.Fatalf("expected zero net unconfirmed balance, got %v", balance.Unconfirmed)
+	}
+
 	// mine another block to confirm the transaction
-	if err := node1.MineBlocks(w.Address(), 1); err != nil {
+	if err := harness.Tick(node1, w.Address()); err != nil {
 		t.Fatal(err)
 	}
-	time.Sleep(500 * time.Millisecond)
 
 	// check that the wallet's balance is the same
-	_, balance, err = w.Balance()
+	balance, err = w.Balance()
 	if err != nil {
 		t.Fatal(err)
-	} else if !balance.Equals(expectedBalance) {
-		t.Fatalf("expected %v balance, got %v", expectedBalance, balance)
+	} else if !balance.Spendable.Equals(expectedBalance) {
+		t.Fatalf("expected %v balance, got %v", expectedBalance, balance.Spendable)
 	}
 
-	// check that the wallet has two transactions
+	// check that the wallet has three transactions: the original payout,
+	// the payout from the block that just confirmed the split transaction,
+	// and the split transaction itself
 	count, err = w.TransactionCount()
 	if err != nil {
 		t.Fatal(err)
-	} else if count != 2 {
-		t.Fatalf("expected 2 transactions, got %v", count)
+	} else if count != 3 {
+		t.Fatalf("expected 3 transactions, got %v", count)
 	}
 
 	// check that the transaction was created at the top of the transaction list
-	txns, err = w.Transactions(100, 0)
+	txns, err = w.Transactions(100, 0, wallet.TransactionFilter{})
 	if err != nil {
 		t.Fatal(err)
-	} else if len(txns) != 2 {
-		t.Fatalf("expected 2 transaction, got %v", len(txns))
+	} else if len(txns) != 3 {
+		t.Fatalf("expected 3 transaction, got %v", len(txns))
 	} else if txns[0].Transaction.ID() != splitTxn.ID() {
 		t.Fatalf("expected transaction %v, got %v", splitTxn.ID(), txns[0].Transaction.ID())
 	} else if txns[0].Source != wallet.TxnSourceTransaction {
 		t.Fatalf("expected transaction source, got %v", txns[0].Source)
 	} else if txns[0].Outflow.Equals(types.ZeroCurrency) {
 		t.Fatalf("expected outflow, got %v", txns[0].Outflow)
+	} else if txns[0].Label != "split" {
+		t.Fatalf("expected label %q, got %q", "split", txns[0].Label)
+	}
+
+	// filtering by label should only return the split transaction
+	labeled, err := w.Transactions(100, 0, wallet.TransactionFilter{Label: "split"})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(labeled) != 1 {
+		t.Fatalf("expected 1 labeled transaction, got %v", len(labeled))
+	} else if labeled[0].Transaction.ID() != splitTxn.ID() {
+		t.Fatalf("expected labeled transaction %v, got %v", splitTxn.ID(), labeled[0].Transaction.ID())
 	}
 
 	// send all the outputs to the burn address individually
@@ -135,23 +168,24 @@ func TestWallet(t *testing.T) {
 	}
 
 	// mine another block to confirm the transactions
-	if err := node1.MineBlocks(w.Address(), 1); err != nil {
+	if err := harness.Tick(node1, w.Address()); err != nil {
 		t.Fatal(err)
 	}
-	time.Sleep(500 * time.Millisecond)
 
-	// check that the wallet now has 22 transactions
+	// check that the wallet now has 24 transactions: 3 miner payouts (the
+	// original, the one confirming the split, and the one confirming these
+	// 20 sends), the split transaction, and the 20 individual sends
 	count, err = w.TransactionCount()
 	if err != nil {
 		t.Fatal(err)
-	} else if count != 22 {
-		t.Fatalf("expected 22 transactions, got %v", count)
+	} else if count != 24 {
+		t.Fatalf("expected 24 transactions, got %v", count)
 	}
 
 	// check that the paginated transactions are in the proper order
 	for i := 0; i < 20; i++ {
 		expectedTxn := sentTransactions[i]
-		txns, err := w.Transactions(1, i)
+		txns, err := w.Transactions(1, i, wallet.TransactionFilter{})
 		if err != nil {
 			t.Fatal(err)
 		} else if len(txns) != 1 {
@@ -179,21 +213,112 @@ func TestWallet(t *testing.T) {
 	if err := node1.ConnectPeer(node2.GatewayAddr()); err != nil {
 		t.Fatal(err)
 	}
-	time.Sleep(time.Second)
+
+	// wait for node1 to converge on node2's chain, rather than sleeping
+	// and hoping the reorg has finished
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	syncHarness := test.NewHarness(node2, node1)
+	if err := syncHarness.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
 
 	// check that the wallet's balance is back to 0
-	_, balance, err = w.Balance()
+	balance, err = w.Balance()
 	if err != nil {
 		t.Fatal(err)
-	} else if !balance.Equals(types.ZeroCurrency) {
+	} else if !balance.Spendable.Equals(types.ZeroCurrency) || !balance.Confirmed.Equals(types.ZeroCurrency) {
 		t.Fatalf("expected zero balance, got %v", balance)
 	}
 
 	// check that all transactions have been deleted
-	txns, err = w.Transactions(0, 100)
+	txns, err = w.Transactions(100, 0, wallet.TransactionFilter{})
 	if err != nil {
 		t.Fatal(err)
 	} else if len(txns) != 0 {
 		t.Fatalf("expected 0 transactions, got %v", len(txns))
 	}
+	if count, err := w.TransactionCount(); err != nil {
+		t.Fatal(err)
+	} else if count != 0 {
+		t.Fatalf("expected 0 transactions, got %v", count)
+	}
+}
+
+// TestTransactionLabelReorg verifies that a label set on a confirmed
+// transaction survives a reorg that reverts it, and reattaches once the
+// same transaction confirms again in the replacement chain.
+func TestTransactionLabelReorg(t *testing.T) {
+	node1, err := test.NewWallet(types.GeneratePrivateKey(), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node1.Close()
+
+	w := node1.Wallet
+	harness := test.NewHarness(node1)
+
+	// mine until the wallet has spendable funds
+	if err := node1.MineBlocks(w.Address(), int(stypes.MaturityDelay)+1); err != nil {
+		t.Fatal(err)
+	}
+
+	// snapshot the chain here; this is the point the competing fork will
+	// branch from, so the block that confirms txn below is the one that
+	// gets reverted
+	forkState := node1.TipState()
+
+	expectedBalance := (consensus.State{}).BlockReward()
+	txn, err := node1.SendSiacoins([]types.SiacoinOutput{{Value: expectedBalance.Div64(2), Address: w.Address()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// mine the block that confirms txn
+	if err := harness.Tick(node1, w.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.LabelTransaction(txn.ID(), "refund", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	txns, err := w.Transactions(100, 0, wallet.TransactionFilter{})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(txns) == 0 || txns[0].Label != "refund" {
+		t.Fatalf("expected labeled transaction, got %+v", txns)
+	}
+
+	// build a fork, from before txn confirmed, that is longer than node1's
+	// current branch; connecting it will revert the block that confirmed
+	// txn without spending the outputs txn consumed
+	if _, err := node1.MineBlocksAt(forkState, types.Address{}, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// txn's inputs were never spent on the winning fork, so it can be
+	// rebroadcast and re-mined under the same id
+	if err := node1.SendTransactionSet([]types.Transaction{txn}); err != nil {
+		t.Fatal(err)
+	}
+	if err := harness.Tick(node1, w.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	txns, err = w.Transactions(100, 0, wallet.TransactionFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, got := range txns {
+		if got.Transaction.ID() == txn.ID() {
+			found = true
+			if got.Label != "refund" {
+				t.Fatalf("expected label %q to reattach, got %q", "refund", got.Label)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("expected re-mined transaction to be present")
+	}
 }
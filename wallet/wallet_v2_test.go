@@ -0,0 +1,69 @@
+package wallet_test
+
+import (
+	"testing"
+
+	"go.sia.tech/core/consensus"
+	"go.sia.tech/core/types"
+	"go.sia.tech/hostd/internal/test"
+	"go.sia.tech/hostd/wallet"
+	stypes "go.sia.tech/siad/types"
+)
+
+// TestWalletV2 exercises the v2 transaction codepath, including activation
+// of the v2 hardfork.
+func TestWalletV2(t *testing.T) {
+	node, err := test.NewWallet(types.GeneratePrivateKey(), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+
+	w := node.Wallet
+
+	// mine until the v2 hardfork has activated and the miner payout has
+	// matured
+	if err := node.MineBlocks(w.Address(), int(stypes.MaturityDelay)+1); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedBalance := (consensus.State{}).BlockReward()
+	balance, err := w.Balance()
+	if err != nil {
+		t.Fatal(err)
+	} else if !balance.Spendable.Equals(expectedBalance) {
+		t.Fatalf("expected %v spendable balance, got %v", expectedBalance, balance.Spendable)
+	}
+
+	// send a v2 transaction
+	sendAmount := expectedBalance.Div64(2)
+	v2Txn, err := node.SendV2Siacoins([]types.SiacoinOutput{
+		{Value: sendAmount, Address: w.Address()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := node.MineBlocks(w.Address(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	txns, err := w.Transactions(100, 0, wallet.TransactionFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found bool
+	for _, txn := range txns {
+		if txn.Source != wallet.TxnSourceV2Transaction {
+			continue
+		}
+		if txn.ID() != v2Txn.ID() {
+			continue
+		}
+		found = true
+	}
+	if !found {
+		t.Fatal("expected to find the broadcast v2 transaction")
+	}
+}